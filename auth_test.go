@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestCheckSignature(t *testing.T) {
+	const secret = "s3kr3t"
+	const body = `{"hello":"world"}`
+	validSig := sign(secret, body)
+
+	cases := []struct {
+		name      string
+		secret    string
+		header    string
+		wantError bool
+	}{
+		{"no secret configured", "", "", false},
+		{"valid sha256= prefixed signature", secret, "sha256=" + validSig, false},
+		{"valid raw hex signature", secret, validSig, false},
+		{"missing header", secret, "", true},
+		{"wrong signature", secret, "sha256=" + sign(secret, "tampered"), true},
+		{"malformed hex", secret, "sha256=not-hex", true},
+	}
+
+	savedSecret, savedHeader := webhookSecret, webhookSignatureHeader
+	defer func() { webhookSecret, webhookSignatureHeader = savedSecret, savedHeader }()
+	webhookSignatureHeader = "X-Hub-Signature-256"
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			webhookSecret = c.secret
+
+			r := httptest.NewRequest("POST", "/event", nil)
+			if len(c.header) > 0 {
+				r.Header.Set(webhookSignatureHeader, c.header)
+			}
+
+			err := checkSignature(r, []byte(body))
+			if c.wantError && err == nil {
+				t.Fatalf("expected an error, got nil")
+			}
+			if !c.wantError && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if err != nil {
+				if _, ok := err.(*BadSignatureError); !ok {
+					t.Fatalf("expected a *BadSignatureError, got %T", err)
+				}
+			}
+		})
+	}
+}
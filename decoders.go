@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Smile-SA/argoos/apiutils"
+)
+
+// Registry type names, selected via the X-Argoos-Registry-Type header or a
+// dedicated /event/<type> route.
+const (
+	RegistryDockerV2 = "dockerv2"
+	RegistryHarbor   = "harbor"
+	RegistryQuay     = "quay"
+	RegistryGHCR     = "ghcr"
+	RegistryGitLab   = "gitlab"
+)
+
+// RegistryDecoder turns a registry-specific webhook payload into the
+// internal event shape ImpactedDeployments already knows how to consume.
+type RegistryDecoder interface {
+	Decode(body []byte, headers http.Header) ([]apiutils.Event, error)
+}
+
+// decoders holds the built-in RegistryDecoder implementations, keyed by
+// registry type name.
+var decoders = map[string]RegistryDecoder{
+	RegistryDockerV2: dockerV2Decoder{},
+	RegistryHarbor:   harborDecoder{},
+	RegistryQuay:     quayDecoder{},
+	RegistryGHCR:     ghcrDecoder{},
+	RegistryGitLab:   gitlabDecoder{},
+}
+
+// decoderFor picks the RegistryDecoder to use for an incoming request: an
+// explicit X-Argoos-Registry-Type header wins, then the route the request
+// came in on, falling back to the historical Docker Registry v2 behavior.
+// It also returns the matched registry type name, used for metrics labels.
+func decoderFor(r *http.Request) (string, RegistryDecoder) {
+	if t := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Argoos-Registry-Type"))); len(t) > 0 {
+		if d, ok := decoders[t]; ok {
+			return t, d
+		}
+	}
+
+	if t := strings.TrimPrefix(r.URL.Path, "/event/"); t != r.URL.Path && len(t) > 0 {
+		if d, ok := decoders[strings.ToLower(t)]; ok {
+			return strings.ToLower(t), d
+		}
+	}
+
+	return RegistryDockerV2, decoders[RegistryDockerV2]
+}
+
+// dockerV2Decoder decodes the Docker Distribution v2 notification envelope,
+// argoos' original and default format.
+type dockerV2Decoder struct{}
+
+func (dockerV2Decoder) Decode(body []byte, headers http.Header) ([]apiutils.Event, error) {
+	registry := headers.Get("X-Argoos-Registry-Name")
+	events := apiutils.GetEvents(body, registry)
+	return events.Events, nil
+}
+
+// harborPayload models the small subset of Harbor's webhook payload argoos
+// needs: https://goharbor.io/docs/latest/working-with-projects/project-configuration/configure-webhooks/
+type harborPayload struct {
+	Type      string `json:"type"`
+	EventData struct {
+		Resources []struct {
+			Digest string `json:"digest"`
+			Tag    string `json:"tag"`
+		} `json:"resources"`
+		Repository struct {
+			Name string `json:"repo_full_name"`
+		} `json:"repository"`
+	} `json:"event_data"`
+}
+
+type harborDecoder struct{}
+
+func (harborDecoder) Decode(body []byte, headers http.Header) ([]apiutils.Event, error) {
+	var payload harborPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("harbor: %s", err.Error())
+	}
+
+	var events []apiutils.Event
+	for _, res := range payload.EventData.Resources {
+		events = append(events, apiutils.Event{
+			Action: payload.Type,
+			Target: apiutils.Target{
+				Repository: payload.EventData.Repository.Name,
+				Tag:        res.Tag,
+				Digest:     res.Digest,
+			},
+		})
+	}
+	return events, nil
+}
+
+// quayPayload models Quay's repository notification payload:
+// https://docs.quay.io/guides/notifications.html
+type quayPayload struct {
+	Repository string   `json:"repository"`
+	DockerTags []string `json:"docker_tags"`
+	DockerURL  string   `json:"docker_url"`
+}
+
+type quayDecoder struct{}
+
+func (quayDecoder) Decode(body []byte, headers http.Header) ([]apiutils.Event, error) {
+	var payload quayPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("quay: %s", err.Error())
+	}
+
+	var events []apiutils.Event
+	for _, tag := range payload.DockerTags {
+		events = append(events, apiutils.Event{
+			Action: "push",
+			Target: apiutils.Target{
+				Repository: payload.Repository,
+				Tag:        tag,
+			},
+		})
+	}
+	return events, nil
+}
+
+// ghcrPayload models the subset of a GitHub "package" webhook event argoos
+// needs: https://docs.github.com/en/webhooks/webhook-events-and-payloads#package
+type ghcrPayload struct {
+	Action  string `json:"action"`
+	Package struct {
+		Name           string `json:"name"`
+		PackageVersion struct {
+			Version string `json:"version"`
+		} `json:"package_version"`
+	} `json:"package"`
+}
+
+type ghcrDecoder struct{}
+
+func (ghcrDecoder) Decode(body []byte, headers http.Header) ([]apiutils.Event, error) {
+	var payload ghcrPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("ghcr: %s", err.Error())
+	}
+
+	return []apiutils.Event{{
+		Action: payload.Action,
+		Target: apiutils.Target{
+			Repository: payload.Package.Name,
+			Tag:        payload.Package.PackageVersion.Version,
+		},
+	}}, nil
+}
+
+// gitlabPayload models GitLab's container registry webhook event:
+// https://docs.gitlab.com/ee/user/project/integrations/webhook_events.html#container-registry-events
+type gitlabPayload struct {
+	EventName string `json:"event_name"`
+	Project   struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+type gitlabDecoder struct{}
+
+func (gitlabDecoder) Decode(body []byte, headers http.Header) ([]apiutils.Event, error) {
+	var payload gitlabPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("gitlab: %s", err.Error())
+	}
+
+	return []apiutils.Event{{
+		Action: payload.EventName,
+		Target: apiutils.Target{
+			Repository: payload.Project.PathWithNamespace,
+		},
+	}}, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHarborDecoder(t *testing.T) {
+	body := []byte(`{
+		"type": "PUSH_ARTIFACT",
+		"event_data": {
+			"resources": [{"digest": "sha256:abc", "tag": "latest"}],
+			"repository": {"repo_full_name": "library/nginx"}
+		}
+	}`)
+
+	events, err := harborDecoder{}.Decode(body, http.Header{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	e := events[0]
+	if e.Action != "PUSH_ARTIFACT" || e.Target.Repository != "library/nginx" ||
+		e.Target.Tag != "latest" || e.Target.Digest != "sha256:abc" {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+}
+
+func TestQuayDecoder(t *testing.T) {
+	body := []byte(`{
+		"repository": "myorg/myapp",
+		"docker_tags": ["latest", "v1.2.3"],
+		"docker_url": "quay.io/myorg/myapp"
+	}`)
+
+	events, err := quayDecoder{}.Decode(body, http.Header{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0].Target.Tag != "latest" || events[1].Target.Tag != "v1.2.3" {
+		t.Fatalf("unexpected tags: %+v", events)
+	}
+}
+
+func TestGHCRDecoder(t *testing.T) {
+	body := []byte(`{
+		"action": "published",
+		"package": {"name": "myapp", "package_version": {"version": "1.0.0"}}
+	}`)
+
+	events, err := ghcrDecoder{}.Decode(body, http.Header{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 1 || events[0].Action != "published" ||
+		events[0].Target.Repository != "myapp" || events[0].Target.Tag != "1.0.0" {
+		t.Fatalf("unexpected event: %+v", events)
+	}
+}
+
+func TestGitLabDecoder(t *testing.T) {
+	body := []byte(`{
+		"event_name": "delete",
+		"project": {"path_with_namespace": "group/project"}
+	}`)
+
+	events, err := gitlabDecoder{}.Decode(body, http.Header{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 1 || events[0].Action != "delete" ||
+		events[0].Target.Repository != "group/project" {
+		t.Fatalf("unexpected event: %+v", events)
+	}
+}
+
+func TestDecodersRejectMalformedJSON(t *testing.T) {
+	for name, d := range map[string]RegistryDecoder{
+		"harbor": harborDecoder{},
+		"quay":   quayDecoder{},
+		"ghcr":   ghcrDecoder{},
+		"gitlab": gitlabDecoder{},
+	} {
+		if _, err := d.Decode([]byte("not json"), http.Header{}); err == nil {
+			t.Errorf("%s: expected an error on malformed JSON", name)
+		}
+	}
+}
+
+func TestDecoderFor(t *testing.T) {
+	cases := []struct {
+		name         string
+		path         string
+		typeHeader   string
+		wantRegistry string
+	}{
+		{"defaults to docker v2", "/event", "", RegistryDockerV2},
+		{"header selects harbor", "/event", RegistryHarbor, RegistryHarbor},
+		{"route selects quay", "/event/quay", "", RegistryQuay},
+		{"unknown header falls back", "/event", "not-a-registry", RegistryDockerV2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", c.path, nil)
+			if len(c.typeHeader) > 0 {
+				r.Header.Set("X-Argoos-Registry-Type", c.typeHeader)
+			}
+			registry, decoder := decoderFor(r)
+			if registry != c.wantRegistry {
+				t.Fatalf("expected registry %q, got %q", c.wantRegistry, registry)
+			}
+			if decoder == nil {
+				t.Fatalf("expected a non-nil decoder")
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+)
+
+var (
+	logFormat = "text"
+	logLevel  = "info"
+)
+
+// logger is argoos' structured logger, configured by configureLogging once
+// flags have been parsed. It defaults to a plain slog.Logger so package
+// init order doesn't matter.
+var logger = slog.Default()
+
+// registerLoggingFlags wires the structured logging flags and their env var
+// equivalents. Called before flag.Parse().
+func registerLoggingFlags() {
+	if v := os.Getenv("LOG_FORMAT"); len(v) > 0 {
+		logFormat = v
+	}
+	if v := os.Getenv("LOG_LEVEL"); len(v) > 0 {
+		logLevel = v
+	}
+
+	flag.StringVar(&logFormat,
+		"log-format",
+		logFormat,
+		"Log output format: text|json")
+	flag.StringVar(&logLevel,
+		"log-level",
+		logLevel,
+		"Minimum log level: debug|info|warn|error")
+}
+
+// configureLogging builds the structured logger from -log-format/-log-level.
+// Called once flags have been parsed.
+func configureLogging() {
+	var level slog.Level
+	switch logLevel {
+	case "debug":
+		level = slog.LevelDebug
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		level = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if logFormat == "json" {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+	logger = slog.New(handler)
+}
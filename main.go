@@ -4,11 +4,14 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/Smile-SA/argoos/apiutils"
 )
@@ -26,16 +29,24 @@ func (b *BadTokenError) Error() string {
 	return "Bad Token"
 }
 
-// signal handling, if server should stop, cleanup goroutines.
-func sig() {
-	c := make(chan os.Signal, 0)
-	signal.Notify(c, os.Interrupt)
-
-	// Block until a signal is received.
-	s := <-c
-	apiutils.StopRollout()
-	log.Println("Got signal", s)
-	os.Exit(0)
+// sig waits for a termination or reload signal. SIGINT/SIGTERM trigger a
+// graceful shutdown of server, draining in-flight requests and rollouts
+// before closing done. SIGHUP is logged but otherwise ignored, as config
+// reload isn't implemented yet. main() must wait on done before exiting:
+// server.Shutdown unblocks serveTLS as soon as the listener closes, well
+// before the drain below has actually finished.
+func sig(server *http.Server, done chan struct{}) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	for s := range c {
+		logger.Info("got signal", "signal", s)
+		if s == syscall.SIGHUP {
+			continue
+		}
+		gracefulShutdown(server, done)
+		return
+	}
 }
 
 func checkToken(r *http.Request) error {
@@ -57,31 +68,66 @@ func checkToken(r *http.Request) error {
 
 // Action is sent each time the registry sends an event.
 func Action(w http.ResponseWriter, r *http.Request) {
-	if err := checkToken(r); err != nil {
-		log.Printf("%s %s %s ERROR::%s\n", r.RemoteAddr, r.Method, r.URL, err.Error())
+	c, _ := ioutil.ReadAll(r.Body)
+	registry, decoder := decoderFor(r)
+	eventsReceivedTotal.WithLabelValues(registry).Inc()
+
+	if err := verifyRequest(r, c); err != nil {
+		reason := "token"
+		if _, ok := err.(*BadSignatureError); ok {
+			reason = "hmac"
+		}
+		authFailuresTotal.WithLabelValues(reason).Inc()
+		logger.Error("auth failed", "remote", r.RemoteAddr, "path", r.URL.String(), "error", err.Error())
 		w.WriteHeader(http.StatusUnauthorized)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
-	log.Printf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL)
-	c, _ := ioutil.ReadAll(r.Body)
-	registry := r.Header.Get("X-Argoos-Registry-Name")
-	if apiutils.Verbose {
-		log.Println("Registry override:", registry)
+	certs := peerCertificates(r)
+	if len(allowedCNs) > 0 || len(certs) > 0 {
+		if err := checkClientCertCN(certs); err != nil {
+			authFailuresTotal.WithLabelValues("client-cert").Inc()
+			logger.Error("auth failed", "remote", r.RemoteAddr, "path", r.URL.String(), "error", err.Error())
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if apiutils.Verbose && len(certs) > 0 {
+			logger.Debug("client cert", "cn", certs[0].Subject.CommonName)
+		}
+	}
+
+	logger.Info("request", "remote", r.RemoteAddr, "method", r.Method, "path", r.URL.String())
+
+	start := time.Now()
+	events, err := decoder.Decode(c, r.Header)
+	timeSince(decodeDuration.WithLabelValues(registry), start)
+	if err != nil {
+		eventsRejectedTotal.WithLabelValues(registry).Inc()
+		logger.Error("decode failed", "remote", r.RemoteAddr, "path", r.URL.String(), "error", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
 	}
-	events := apiutils.GetEvents(c, registry)
-	for _, e := range events.Events {
-		apiutils.ImpactedDeployments(e)
+
+	for _, e := range events {
+		eventsDecodedTotal.WithLabelValues(registry, e.Action).Inc()
+		deployStart := time.Now()
+		trackRollout(e)
+		timeSince(impactedDeploymentsDuration, deployStart)
 	}
 }
 
 // Health return always "ok" with 200 OK. Usefull to check liveness.
 func Health(w http.ResponseWriter, r *http.Request) {
-	log.Printf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL)
+	logger.Info("request", "remote", r.RemoteAddr, "method", r.Method, "path", r.URL.String())
 	w.Write([]byte("ok\n"))
 }
 
+// Metrics serves Prometheus metrics in text format.
+var Metrics = promhttp.Handler()
+
 func main() {
 	host := ":3000"
 	var servercert, serverkey string
@@ -198,26 +244,54 @@ func main() {
 		TOKEN,
 		"Token that should be sent by docker registry to be authorized. If set, you must add token in X-Argoos-Token header.")
 
+	registerTLSFlags()
+	registerMTLSFlags()
+	registerAuthFlags()
+	registerLoggingFlags()
+	registerShutdownFlags()
+
 	flag.Parse()
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "tls" {
+			tlsExplicit = true
+		}
+	})
 
 	if askedVersion {
 		fmt.Println(VERSION)
 		os.Exit(0)
 	}
 
+	if !tlsExplicit && len(servercert) > 0 && len(serverkey) > 0 {
+		// Kept for backward compatibility with the pre-ACME -server-cert/-server-key flags.
+		tlsType = tlsManual
+	}
+
+	configureLogging()
+
 	apiutils.Config()
-	go sig()
 	apiutils.StartRollout()
 
-	log.Println("Starting")
+	logger.Info("starting")
 
 	http.HandleFunc("/healthz", Health)
+	http.HandleFunc("/readyz", Readyz)
 	http.HandleFunc("/event", Action)
+	http.HandleFunc("/event/", Action)
+	http.Handle("/metrics", Metrics)
+
+	server := &http.Server{}
+	done := make(chan struct{})
+	go sig(server, done)
 
-	if len(serverkey) > 0 && len(servercert) > 0 {
-		log.Fatal(http.ListenAndServeTLS(host, servercert, serverkey, nil))
-	} else {
-		log.Fatal(http.ListenAndServe(host, nil))
+	if err := serveTLS(server, host, servercert, serverkey); err != nil {
+		logger.Error("serve failed", "error", err.Error())
+		os.Exit(1)
 	}
 
+	// serveTLS only returns nil once the listener has been closed by
+	// gracefulShutdown; wait for it to actually finish draining before
+	// exiting, instead of trusting that return to mean "safe to exit".
+	<-done
+	logger.Info("shutdown complete")
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	eventsReceivedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "argoos_events_received_total",
+		Help: "Webhook events received, by registry type.",
+	}, []string{"registry"})
+
+	eventsDecodedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "argoos_events_decoded_total",
+		Help: "Webhook events successfully decoded, by registry type and action.",
+	}, []string{"registry", "action"})
+
+	eventsRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "argoos_events_rejected_total",
+		Help: "Webhook events rejected, by registry type.",
+	}, []string{"registry"})
+
+	authFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "argoos_auth_failures_total",
+		Help: "Authentication failures on /event, by reason.",
+	}, []string{"reason"})
+
+	decodeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "argoos_decode_duration_seconds",
+		Help: "Time spent decoding a webhook payload, by registry type.",
+	}, []string{"registry"})
+
+	impactedDeploymentsDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "argoos_impacted_deployments_duration_seconds",
+		Help: "Time spent computing and triggering rollouts for a decoded event.",
+	})
+
+	// rolloutsInFlight tracks calls to apiutils.ImpactedDeployments currently
+	// executing (see trackRollout in rollouts.go), since apiutils doesn't
+	// expose its internal worker queue depth for argoos to read directly.
+	rolloutsInFlight = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "argoos_rollouts_in_flight",
+		Help: "Calls to ImpactedDeployments currently in flight.",
+	}, func() float64 {
+		return float64(atomic.LoadInt64(&inFlightRollouts))
+	})
+)
+
+// timeSince is a small helper so call sites read as a single observe line.
+func timeSince(h prometheus.Histogram, start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
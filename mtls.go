@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Client auth modes accepted by the -client-auth flag. They mirror the
+// tls.ClientAuthType values argoos actually cares about.
+const (
+	clientAuthNone             = "none"
+	clientAuthRequest          = "request"
+	clientAuthRequireAndVerify = "require-and-verify"
+)
+
+var (
+	clientCAFile   string
+	clientAuthMode = clientAuthNone
+	allowedCNs     stringList
+)
+
+// registerMTLSFlags wires the client certificate authentication flags and
+// their env var equivalents. Called before flag.Parse().
+func registerMTLSFlags() {
+	if v := os.Getenv("CLIENT_CA_FILE"); len(v) > 0 {
+		clientCAFile = v
+	}
+	if v := os.Getenv("CLIENT_AUTH"); len(v) > 0 {
+		clientAuthMode = v
+	}
+	if v := os.Getenv("ALLOWED_CN"); len(v) > 0 {
+		for _, cn := range strings.Split(v, ",") {
+			allowedCNs = append(allowedCNs, strings.TrimSpace(cn))
+		}
+	}
+
+	flag.StringVar(&clientCAFile,
+		"client-ca-file",
+		clientCAFile,
+		"PEM bundle of CA certificates trusted to sign client certificates")
+	flag.StringVar(&clientAuthMode,
+		"client-auth",
+		clientAuthMode,
+		"Client certificate authentication mode: none|request|require-and-verify")
+	flag.Var(&allowedCNs,
+		"allowed-cn",
+		"Client certificate CommonName allowed to call /event (repeatable, defaults to any CN the TLS handshake accepts)")
+}
+
+// certPoolFromFile parses a PEM bundle of one or more concatenated
+// certificates into a CA pool usable as tls.Config.ClientCAs.
+func certPoolFromFile(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return pool, nil
+}
+
+// clientAuthType maps the -client-auth flag value to its tls.ClientAuthType.
+func clientAuthType() (tls.ClientAuthType, error) {
+	switch clientAuthMode {
+	case clientAuthNone, "":
+		return tls.NoClientCert, nil
+	case clientAuthRequest:
+		return tls.RequestClientCert, nil
+	case clientAuthRequireAndVerify:
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown -client-auth mode %q", clientAuthMode)
+	}
+}
+
+// applyClientAuth layers client certificate authentication onto an already
+// built server tls.Config, when -client-ca-file/-client-auth are set.
+func applyClientAuth(cfg *tls.Config) error {
+	if len(clientCAFile) == 0 && clientAuthMode == clientAuthNone {
+		return nil
+	}
+
+	authType, err := clientAuthType()
+	if err != nil {
+		return err
+	}
+	cfg.ClientAuth = authType
+
+	if len(clientCAFile) > 0 {
+		pool, err := certPoolFromFile(clientCAFile)
+		if err != nil {
+			return err
+		}
+		cfg.ClientCAs = pool
+	}
+	return nil
+}
+
+// peerCertificates returns the client certificates presented on r's TLS
+// connection, or nil over plain HTTP or when none were sent.
+func peerCertificates(r *http.Request) []*x509.Certificate {
+	if r.TLS == nil {
+		return nil
+	}
+	return r.TLS.PeerCertificates
+}
+
+// checkClientCertCN authorizes the request's client certificate CommonName
+// against -allowed-cn, whenever an allow-list is configured; a request that
+// presents no client certificate (including one over plain HTTP) is then
+// rejected. With no allow-list, any certificate the TLS handshake accepted is
+// authorized; with -client-auth=request that handshake does not verify the
+// certificate against -client-ca-file.
+func checkClientCertCN(peerCerts []*x509.Certificate) error {
+	if len(allowedCNs) == 0 {
+		return nil
+	}
+	if len(peerCerts) == 0 {
+		return fmt.Errorf("no client certificate presented")
+	}
+	cn := peerCerts[0].Subject.CommonName
+	for _, allowed := range allowedCNs {
+		if cn == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("client certificate CN %q is not allowed", cn)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+// selfSignedCert generates a throwaway self-signed certificate for a given
+// CommonName, returning both the parsed certificate and its PEM encoding, for
+// exercising certPoolFromFile/checkClientCertCN.
+func selfSignedCert(t *testing.T, cn string) (*x509.Certificate, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return cert, pemBytes
+}
+
+func TestCertPoolFromFile(t *testing.T) {
+	_, certPEM := selfSignedCert(t, "trusted-ca")
+
+	f, err := os.CreateTemp(t.TempDir(), "ca-*.pem")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	if _, err := f.Write(certPEM); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+	f.Close()
+
+	pool, err := certPoolFromFile(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pool == nil {
+		t.Fatalf("expected a non-nil cert pool")
+	}
+
+	if _, err := certPoolFromFile(f.Name() + "-missing"); err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+
+	empty, err := os.CreateTemp(t.TempDir(), "empty-*.pem")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	empty.Close()
+	if _, err := certPoolFromFile(empty.Name()); err == nil {
+		t.Fatalf("expected an error for a file with no certificates")
+	}
+}
+
+func TestCheckClientCertCN(t *testing.T) {
+	allowed, _ := selfSignedCert(t, "allowed-client")
+	other, _ := selfSignedCert(t, "other-client")
+
+	savedAllowed := allowedCNs
+	defer func() { allowedCNs = savedAllowed }()
+
+	t.Run("no allow-list authorizes any cert", func(t *testing.T) {
+		allowedCNs = nil
+		if err := checkClientCertCN([]*x509.Certificate{other}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("allowed CN passes", func(t *testing.T) {
+		allowedCNs = stringList{"allowed-client"}
+		if err := checkClientCertCN([]*x509.Certificate{allowed}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("disallowed CN is rejected", func(t *testing.T) {
+		allowedCNs = stringList{"allowed-client"}
+		if err := checkClientCertCN([]*x509.Certificate{other}); err == nil {
+			t.Fatalf("expected an error for a disallowed CN")
+		}
+	})
+
+	t.Run("no certificate is rejected when allow-list is set", func(t *testing.T) {
+		allowedCNs = stringList{"allowed-client"}
+		if err := checkClientCertCN(nil); err == nil {
+			t.Fatalf("expected an error when no certificate is presented")
+		}
+	})
+}
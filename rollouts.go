@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Smile-SA/argoos/apiutils"
+)
+
+// inFlightRollouts counts calls to apiutils.ImpactedDeployments currently
+// executing in this process. apiutils doesn't expose its internal worker
+// queue depth, so this is the most accurate visibility argoos has into
+// "rollouts in flight" without instrumenting apiutils itself.
+var inFlightRollouts int64
+
+// trackRollout calls apiutils.ImpactedDeployments while keeping
+// inFlightRollouts accurate, so the Prometheus gauge reflects real
+// in-flight work.
+func trackRollout(e apiutils.Event) {
+	atomic.AddInt64(&inFlightRollouts, 1)
+	defer atomic.AddInt64(&inFlightRollouts, -1)
+	apiutils.ImpactedDeployments(e)
+}
+
+// waitRollouts blocks until inFlightRollouts drops to zero or ctx is done,
+// whichever comes first.
+func waitRollouts(ctx context.Context) error {
+	if atomic.LoadInt64(&inFlightRollouts) == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if atomic.LoadInt64(&inFlightRollouts) == 0 {
+				return nil
+			}
+		}
+	}
+}
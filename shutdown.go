@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/Smile-SA/argoos/apiutils"
+)
+
+var shutdownTimeout = 30 * time.Second
+
+// registerShutdownFlags wires the graceful shutdown flags and their env var
+// equivalents. Called before flag.Parse().
+func registerShutdownFlags() {
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); len(v) > 0 {
+		if d, err := time.ParseDuration(v); err == nil {
+			shutdownTimeout = d
+		}
+	}
+
+	flag.DurationVar(&shutdownTimeout,
+		"shutdown-timeout",
+		shutdownTimeout,
+		"How long to wait for in-flight requests and rollouts to drain before exiting")
+}
+
+// ready flips to false as soon as a shutdown starts, so /readyz fails before
+// the process actually stops accepting connections.
+var ready int32 = 1
+
+// Readyz reports whether argoos is ready to receive traffic. It differs
+// from Health/healthz in that it starts failing during shutdown, so
+// Kubernetes can stop routing to this pod before it exits.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&ready) == 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("shutting down\n"))
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// gracefulShutdown stops the HTTP server from accepting new connections,
+// drains in-flight requests, calls apiutils.StopRollout, then waits until no
+// trackRollout call is still running, all bounded by -shutdown-timeout,
+// before closing done to signal main() it's safe to exit. Only
+// ImpactedDeployments calls made through trackRollout are waited for; work
+// queued inside apiutils' rollout workers is not counted by that wait.
+func gracefulShutdown(server *http.Server, done chan struct{}) {
+	defer close(done)
+	atomic.StoreInt32(&ready, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("server shutdown", "error", err.Error())
+	}
+
+	apiutils.StopRollout()
+	if err := waitRollouts(ctx); err != nil {
+		logger.Error("rollout drain", "error", err.Error())
+	}
+}
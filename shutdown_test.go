@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadyz(t *testing.T) {
+	savedReady := atomic.LoadInt32(&ready)
+	defer atomic.StoreInt32(&ready, savedReady)
+
+	atomic.StoreInt32(&ready, 1)
+	w := httptest.NewRecorder()
+	Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != 200 {
+		t.Fatalf("expected 200 while ready, got %d", w.Code)
+	}
+
+	atomic.StoreInt32(&ready, 0)
+	w = httptest.NewRecorder()
+	Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != 503 {
+		t.Fatalf("expected 503 while shutting down, got %d", w.Code)
+	}
+}
+
+func TestWaitRolloutsReturnsImmediatelyWhenIdle(t *testing.T) {
+	savedInFlight := atomic.LoadInt64(&inFlightRollouts)
+	defer atomic.StoreInt64(&inFlightRollouts, savedInFlight)
+	atomic.StoreInt64(&inFlightRollouts, 0)
+
+	if err := waitRollouts(context.Background()); err != nil {
+		t.Fatalf("expected no error when nothing is in flight, got %v", err)
+	}
+}
+
+func TestWaitRolloutsDrainsBeforeReturning(t *testing.T) {
+	savedInFlight := atomic.LoadInt64(&inFlightRollouts)
+	defer atomic.StoreInt64(&inFlightRollouts, savedInFlight)
+	atomic.StoreInt64(&inFlightRollouts, 1)
+
+	go func() {
+		time.Sleep(75 * time.Millisecond)
+		atomic.StoreInt64(&inFlightRollouts, 0)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := waitRollouts(ctx); err != nil {
+		t.Fatalf("expected waitRollouts to succeed once the rollout drains, got %v", err)
+	}
+}
+
+func TestWaitRolloutsRespectsContextDeadline(t *testing.T) {
+	savedInFlight := atomic.LoadInt64(&inFlightRollouts)
+	defer atomic.StoreInt64(&inFlightRollouts, savedInFlight)
+	atomic.StoreInt64(&inFlightRollouts, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := waitRollouts(ctx); err == nil {
+		t.Fatalf("expected waitRollouts to report the context deadline, got nil")
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/Smile-SA/argoos/apiutils"
+)
+
+// TLS modes accepted by the -tls flag.
+const (
+	tlsInsecure = "insecure"
+	tlsManual   = "manual"
+	tlsACME     = "acme"
+)
+
+// stringList collects repeatable flags, such as -acme-domain or -allowed-cn,
+// into a slice.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+var (
+	tlsType          = tlsInsecure
+	acmeDomains      stringList
+	acmeEmail        string
+	acmeCacheDir     = "/var/run/argoos/acme-cache"
+	acmeDirectoryURL string
+
+	// tlsExplicit records whether -tls/TLS was actually set by the user,
+	// rather than left at its tlsInsecure zero value, so the -server-cert/
+	// -server-key backward-compat shim in main() doesn't override an
+	// explicit -tls=insecure.
+	tlsExplicit bool
+)
+
+// registerTLSFlags wires the ACME/TLS flags and their env var equivalents.
+// Called before flag.Parse(), like the rest of argoos' configuration.
+func registerTLSFlags() {
+	if v := os.Getenv("TLS"); len(v) > 0 {
+		tlsType = v
+		tlsExplicit = true
+	}
+	if v := os.Getenv("ACME_DOMAIN"); len(v) > 0 {
+		for _, d := range strings.Split(v, ",") {
+			acmeDomains = append(acmeDomains, strings.TrimSpace(d))
+		}
+	}
+	if v := os.Getenv("ACME_EMAIL"); len(v) > 0 {
+		acmeEmail = v
+	}
+	if v := os.Getenv("ACME_CACHE_DIR"); len(v) > 0 {
+		acmeCacheDir = v
+	}
+	if v := os.Getenv("ACME_DIRECTORY_URL"); len(v) > 0 {
+		acmeDirectoryURL = v
+	}
+
+	flag.StringVar(&tlsType,
+		"tls",
+		tlsType,
+		"How argoos serves the webhook listener: acme|manual|insecure")
+	flag.Var(&acmeDomains,
+		"acme-domain",
+		"Domain the ACME certificate should cover (repeatable)")
+	flag.StringVar(&acmeEmail,
+		"acme-email",
+		acmeEmail,
+		"Contact email passed to the ACME provider")
+	flag.StringVar(&acmeCacheDir,
+		"acme-cache-dir",
+		acmeCacheDir,
+		"Directory used to cache ACME certificates (with -incluster a Secret is used instead, unless the Kubernetes client can't be built)")
+	flag.StringVar(&acmeDirectoryURL,
+		"acme-directory-url",
+		acmeDirectoryURL,
+		"ACME directory URL, defaults to Let's Encrypt production when empty")
+}
+
+// acmeManager builds the autocert.Manager used when -tls=acme.
+func acmeManager() *autocert.Manager {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(acmeDomains...),
+		Email:      acmeEmail,
+		Cache:      acmeCache(),
+	}
+	if len(acmeDirectoryURL) > 0 {
+		m.Client = &acme.Client{DirectoryURL: acmeDirectoryURL}
+	}
+	return m
+}
+
+// acmeCache picks a Secret-backed cache when running in a Kubernetes
+// cluster, so renewed certificates survive pod restarts, falling back to a
+// plain on-disk DirCache otherwise.
+func acmeCache() autocert.Cache {
+	if apiutils.InCluster {
+		client, ns, err := kubeSecretClient()
+		if err != nil {
+			logger.Error("acme: falling back to disk cache", "error", err.Error())
+			return autocert.DirCache(acmeCacheDir)
+		}
+		return &secretCache{client: client, namespace: ns, name: "argoos-acme-cache"}
+	}
+	return autocert.DirCache(acmeCacheDir)
+}
+
+// restConfig builds a *rest.Config from the same -incluster/-master/-ca-file/
+// -cert-file/-key-file/-skip-ssl-verification flags apiutils.Config() uses,
+// so the ACME Secret cache talks to the API server the same way the rest of
+// argoos does, without a second client construction path to keep in sync.
+func restConfig() (*rest.Config, error) {
+	if apiutils.InCluster {
+		return rest.InClusterConfig()
+	}
+	return &rest.Config{
+		Host: apiutils.KubeMasterURL,
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure: apiutils.SkipSSLVerification,
+			CAFile:   apiutils.CAFile,
+			CertFile: apiutils.CertFile,
+			KeyFile:  apiutils.KeyFile,
+		},
+	}, nil
+}
+
+// kubeSecretClient builds a Kubernetes client for the ACME Secret cache from
+// the same connection settings apiutils.Config() uses, instead of opening a
+// second, differently-authenticated connection to the API server.
+func kubeSecretClient() (corev1client, string, error) {
+	cfg, err := restConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	ns := "default"
+	if v, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+		ns = strings.TrimSpace(string(v))
+	}
+	return clientset.CoreV1().Secrets(ns), ns, nil
+}
+
+type corev1client interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Secret, error)
+	Create(ctx context.Context, secret *corev1.Secret, opts metav1.CreateOptions) (*corev1.Secret, error)
+	Update(ctx context.Context, secret *corev1.Secret, opts metav1.UpdateOptions) (*corev1.Secret, error)
+}
+
+// secretCache implements autocert.Cache on top of a single Kubernetes
+// Secret, one data key per cache key, so ACME certificates survive argoos
+// pod restarts instead of being re-issued every time.
+type secretCache struct {
+	client    corev1client
+	namespace string
+	name      string
+}
+
+func (s *secretCache) Get(ctx context.Context, key string) ([]byte, error) {
+	secret, err := s.client.Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, autocert.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := secret.Data[key]
+	if !ok {
+		return nil, autocert.ErrCacheMiss
+	}
+	return data, nil
+}
+
+func (s *secretCache) Put(ctx context.Context, key string, data []byte) error {
+	secret, err := s.client.Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+			Data:       map[string][]byte{},
+		}
+		secret.Data[key] = data
+		_, err = s.client.Create(ctx, secret, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[key] = data
+	_, err = s.client.Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+func (s *secretCache) Delete(ctx context.Context, key string) error {
+	secret, err := s.client.Get(ctx, s.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	delete(secret.Data, key)
+	_, err = s.client.Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// serveTLS starts server according to tlsType: acme obtains and renews
+// certificates automatically, manual serves the pre-issued
+// -server-cert/-server-key pair, insecure serves plain HTTP. server's Handler
+// is left as the caller set it (nil serves http.DefaultServeMux); serveTLS
+// only fills in Addr and TLSConfig before starting it, so callers keep a
+// single *http.Server to Shutdown on exit.
+func serveTLS(server *http.Server, host, servercert, serverkey string) error {
+	switch tlsType {
+	case tlsACME:
+		if len(acmeDomains) == 0 {
+			return fmt.Errorf("-tls=acme requires at least one -acme-domain")
+		}
+		m := acmeManager()
+		go func() {
+			logger.Info("starting HTTP-01 challenge listener", "addr", ":80")
+			if err := http.ListenAndServe(":80", m.HTTPHandler(nil)); err != nil {
+				logger.Error("HTTP-01 challenge listener stopped", "error", err.Error())
+			}
+		}()
+		tlsConfig := m.TLSConfig()
+		if err := applyClientAuth(tlsConfig); err != nil {
+			return err
+		}
+		server.Addr = ":443"
+		server.TLSConfig = tlsConfig
+		err := server.ListenAndServeTLS("", "")
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	case tlsManual:
+		if len(servercert) == 0 || len(serverkey) == 0 {
+			return fmt.Errorf("-tls=manual requires -server-cert and -server-key")
+		}
+		cert, err := tls.LoadX509KeyPair(servercert, serverkey)
+		if err != nil {
+			return err
+		}
+		tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+		if err := applyClientAuth(tlsConfig); err != nil {
+			return err
+		}
+		server.Addr = host
+		server.TLSConfig = tlsConfig
+		err = server.ListenAndServeTLS("", "")
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	default:
+		server.Addr = host
+		err := server.ListenAndServe()
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	}
+}
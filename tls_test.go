@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/crypto/acme/autocert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeSecretClient is a minimal in-memory corev1client, just enough to
+// exercise secretCache's Get/Put/Delete without a real API server.
+type fakeSecretClient struct {
+	secret *corev1.Secret
+}
+
+func (f *fakeSecretClient) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Secret, error) {
+	if f.secret == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+	}
+	return f.secret.DeepCopy(), nil
+}
+
+func (f *fakeSecretClient) Create(ctx context.Context, secret *corev1.Secret, opts metav1.CreateOptions) (*corev1.Secret, error) {
+	f.secret = secret.DeepCopy()
+	return f.secret, nil
+}
+
+func (f *fakeSecretClient) Update(ctx context.Context, secret *corev1.Secret, opts metav1.UpdateOptions) (*corev1.Secret, error) {
+	f.secret = secret.DeepCopy()
+	return f.secret, nil
+}
+
+func TestSecretCacheGetMiss(t *testing.T) {
+	c := &secretCache{client: &fakeSecretClient{}, namespace: "default", name: "argoos-acme-cache"}
+	if _, err := c.Get(context.Background(), "some-key"); err != autocert.ErrCacheMiss {
+		t.Fatalf("expected ErrCacheMiss for a missing secret, got %v", err)
+	}
+}
+
+func TestSecretCachePutThenGet(t *testing.T) {
+	c := &secretCache{client: &fakeSecretClient{}, namespace: "default", name: "argoos-acme-cache"}
+
+	if err := c.Put(context.Background(), "some-key", []byte("some-data")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+
+	got, err := c.Get(context.Background(), "some-key")
+	if err != nil {
+		t.Fatalf("unexpected error on Get: %v", err)
+	}
+	if string(got) != "some-data" {
+		t.Fatalf("expected %q, got %q", "some-data", got)
+	}
+
+	if _, err := c.Get(context.Background(), "other-key"); err != autocert.ErrCacheMiss {
+		t.Fatalf("expected ErrCacheMiss for an unset key, got %v", err)
+	}
+}
+
+func TestSecretCachePutUpdatesExisting(t *testing.T) {
+	client := &fakeSecretClient{}
+	c := &secretCache{client: client, namespace: "default", name: "argoos-acme-cache"}
+
+	if err := c.Put(context.Background(), "key-a", []byte("a")); err != nil {
+		t.Fatalf("unexpected error on first Put: %v", err)
+	}
+	if err := c.Put(context.Background(), "key-b", []byte("b")); err != nil {
+		t.Fatalf("unexpected error on second Put: %v", err)
+	}
+
+	for key, want := range map[string]string{"key-a": "a", "key-b": "b"} {
+		got, err := c.Get(context.Background(), key)
+		if err != nil {
+			t.Fatalf("unexpected error on Get(%q): %v", key, err)
+		}
+		if string(got) != want {
+			t.Fatalf("Get(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSecretCacheDelete(t *testing.T) {
+	c := &secretCache{client: &fakeSecretClient{}, namespace: "default", name: "argoos-acme-cache"}
+
+	if err := c.Put(context.Background(), "some-key", []byte("some-data")); err != nil {
+		t.Fatalf("unexpected error on Put: %v", err)
+	}
+	if err := c.Delete(context.Background(), "some-key"); err != nil {
+		t.Fatalf("unexpected error on Delete: %v", err)
+	}
+	if _, err := c.Get(context.Background(), "some-key"); err != autocert.ErrCacheMiss {
+		t.Fatalf("expected ErrCacheMiss after Delete, got %v", err)
+	}
+}